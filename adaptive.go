@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcome is the result of an operation governed by an AdaptiveLimiter,
+// reported back via Report so the limiter can retune its own rate.
+type Outcome int
+
+const (
+	// Success indicates the operation completed without being throttled.
+	Success Outcome = iota
+	// Throttled indicates the upstream rejected the operation as
+	// overloaded (e.g. HTTP 429, or MinIO's SlowDown).
+	Throttled
+	// Error indicates the operation failed for a reason other than
+	// throttling.
+	Error
+)
+
+// AdaptiveLimiter wraps a Limiter with an additive-increase,
+// multiplicative-decrease (AIMD) feedback loop: on every Success it raises
+// the limit by Step, up to Ceiling; on a Throttled or Error report it halves
+// the limit, down to Floor, and backs off via SleepandReset.
+type AdaptiveLimiter struct {
+	// Step is added to the current limit on every reported Success.
+	Step uint
+	// Ceiling bounds how high Step can raise the limit.
+	Ceiling uint
+	// Floor bounds how low a multiplicative decrease can take the limit.
+	// NewAdaptiveLimiter clamps it to at least 1.
+	Floor uint
+	// Interval is the refill period used when the limit is adjusted.
+	Interval time.Duration
+	// DefaultBackoff is the sleep used on a Throttled/Error report when no
+	// Retry-After is supplied.
+	DefaultBackoff time.Duration
+
+	limiter *Limiter
+
+	mu    sync.Mutex
+	limit uint
+}
+
+// NewAdaptiveLimiter wraps limiter, whose current limit becomes the AIMD
+// loop's starting point. floor is clamped to at least 1: a limit of 0 would
+// permanently brick the underlying Limiter, which never refills with
+// maxCount 0.
+func NewAdaptiveLimiter(limiter *Limiter, step, ceiling, floor uint, interval, defaultBackoff time.Duration) *AdaptiveLimiter {
+	if floor < 1 {
+		floor = 1
+	}
+	return &AdaptiveLimiter{
+		Step:           step,
+		Ceiling:        ceiling,
+		Floor:          floor,
+		Interval:       interval,
+		DefaultBackoff: defaultBackoff,
+		limiter:        limiter,
+		limit:          limiter.GetLimit(),
+	}
+}
+
+// Limiter returns the underlying Limiter, for Take/Allow/Wait/Reserve calls.
+func (a *AdaptiveLimiter) Limiter() *Limiter {
+	return a.limiter
+}
+
+// Report records the outcome of an operation and retunes the limit
+// accordingly. retryAfter is the upstream's Retry-After duration, if any; it
+// is used as the backoff sleep on a Throttled or Error report instead of
+// DefaultBackoff when positive.
+func (a *AdaptiveLimiter) Report(outcome Outcome, retryAfter time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch outcome {
+	case Success:
+		newLimit := a.limit + a.Step
+		if newLimit > a.Ceiling {
+			newLimit = a.Ceiling
+		}
+		if newLimit == a.limit {
+			return
+		}
+		a.limit = newLimit
+		a.limiter.SleepandReset(0, a.limit, a.Interval)
+	case Throttled, Error:
+		newLimit := a.limit / 2
+		if newLimit < a.Floor {
+			newLimit = a.Floor
+		}
+		a.limit = newLimit
+
+		backoff := a.DefaultBackoff
+		if retryAfter > 0 {
+			backoff = retryAfter
+		}
+		a.limiter.SleepandReset(backoff, a.limit, a.Interval)
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimiterSuccessRaisesLimitToCeiling(t *testing.T) {
+	l := New(context.Background(), 2, 10*time.Millisecond)
+	defer l.Stop()
+	a := NewAdaptiveLimiter(l, 2, 5, 1, 10*time.Millisecond, time.Millisecond)
+
+	a.Report(Success, 0)
+	a.Report(Success, 0)
+	a.Report(Success, 0) // would overshoot to 8, clamped to Ceiling
+
+	if got := l.GetLimit(); got != 5 {
+		t.Fatalf("GetLimit() = %d, want 5 (clamped to Ceiling)", got)
+	}
+}
+
+func TestAdaptiveLimiterThrottledHalvesLimit(t *testing.T) {
+	l := New(context.Background(), 8, 10*time.Millisecond)
+	defer l.Stop()
+	a := NewAdaptiveLimiter(l, 1, 16, 1, 10*time.Millisecond, time.Millisecond)
+
+	a.Report(Throttled, 0)
+
+	if got := l.GetLimit(); got != 4 {
+		t.Fatalf("GetLimit() = %d, want 4", got)
+	}
+}
+
+func TestAdaptiveLimiterNeverDecreasesBelowFloor(t *testing.T) {
+	l := New(context.Background(), 1, 10*time.Millisecond)
+	defer l.Stop()
+	// Floor of 0 would otherwise brick the limiter: maxCount 0 never
+	// refills, so NewAdaptiveLimiter must clamp it to 1.
+	a := NewAdaptiveLimiter(l, 1, 16, 0, 10*time.Millisecond, time.Millisecond)
+
+	a.Report(Throttled, 0)
+	a.Report(Error, 0)
+
+	if got := l.GetLimit(); got != 1 {
+		t.Fatalf("GetLimit() = %d, want 1 (Floor clamped to a minimum of 1)", got)
+	}
+	if !l.Allow() {
+		t.Fatal("limiter should still grant tokens after repeated throttling, not be bricked at limit 0")
+	}
+}
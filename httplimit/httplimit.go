@@ -0,0 +1,180 @@
+// Package httplimit wraps ratelimit.Limiter into net/http middleware,
+// lazily maintaining one limiter per key (IP, API key, user id, ...).
+package httplimit
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hlnths/ratelimit"
+)
+
+// KeyFunc extracts the rate-limit key from an incoming request.
+type KeyFunc func(*http.Request) string
+
+// Mode controls what the middleware does when a key has no token available.
+type Mode int
+
+const (
+	// Reject responds immediately with 429 Too Many Requests.
+	Reject Mode = iota
+	// Queue waits up to QueueTimeout for a token, bound to the request's
+	// context, before responding with 429 Too Many Requests.
+	Queue
+)
+
+// Options configures a Limiter middleware.
+type Options struct {
+	// Limit is the number of requests allowed per Interval, per key.
+	Limit uint
+	// Interval is the refill period for Limit.
+	Interval time.Duration
+	// KeyFunc extracts the rate-limit key from the request. Defaults to the
+	// request's RemoteAddr.
+	KeyFunc KeyFunc
+	// Mode selects the behaviour on a missed token. Defaults to Reject.
+	Mode Mode
+	// QueueTimeout bounds how long a request waits for a token in Queue
+	// mode. Defaults to Interval.
+	QueueTimeout time.Duration
+	// MaxKeys bounds the number of per-key limiters kept alive at once,
+	// evicting the least recently used. Zero means unbounded.
+	MaxKeys int
+	// IdleTTL is how long an unused per-key limiter is kept before being
+	// stopped and evicted. Zero disables TTL-based eviction.
+	IdleTTL time.Duration
+}
+
+// Limiter is net/http middleware applying a per-key ratelimit.Limiter.
+type Limiter struct {
+	opts Options
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+}
+
+type entry struct {
+	key      string
+	limiter  *ratelimit.Limiter
+	lastUsed time.Time
+}
+
+// New creates a Limiter middleware from opts.
+func New(opts Options) *Limiter {
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = func(r *http.Request) string { return r.RemoteAddr }
+	}
+	if opts.QueueTimeout == 0 {
+		opts.QueueTimeout = opts.Interval
+	}
+	return &Limiter{
+		opts:    opts,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Wrap returns next wrapped with the per-key rate limit.
+func (l *Limiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl := l.limiterFor(l.opts.KeyFunc(r))
+
+		if l.opts.Mode == Queue {
+			ctx, cancel := context.WithTimeout(r.Context(), l.opts.QueueTimeout)
+			defer cancel()
+			if err := rl.Wait(ctx); err != nil {
+				l.writeTooManyRequests(w, rl)
+				return
+			}
+		} else if !rl.Allow() {
+			l.writeTooManyRequests(w, rl)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stop releases every per-key limiter currently held by l. Call it once the
+// middleware is no longer in use to stop their background goroutines.
+func (l *Limiter) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for el := l.lru.Front(); el != nil; el = el.Next() {
+		el.Value.(*entry).limiter.Stop()
+	}
+	l.entries = make(map[string]*list.Element)
+	l.lru.Init()
+}
+
+// limiterFor returns the limiter for key, creating it on first use and
+// evicting expired or excess entries.
+func (l *Limiter) limiterFor(key string) *ratelimit.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictExpiredLocked()
+
+	if el, ok := l.entries[key]; ok {
+		l.lru.MoveToFront(el)
+		e := el.Value.(*entry)
+		e.lastUsed = time.Now()
+		return e.limiter
+	}
+
+	e := &entry{
+		key:      key,
+		limiter:  ratelimit.New(context.Background(), l.opts.Limit, l.opts.Interval),
+		lastUsed: time.Now(),
+	}
+	l.entries[key] = l.lru.PushFront(e)
+
+	if l.opts.MaxKeys > 0 && l.lru.Len() > l.opts.MaxKeys {
+		l.removeLocked(l.lru.Back())
+	}
+
+	return e.limiter
+}
+
+// evictExpiredLocked removes entries idle for longer than IdleTTL. l.mu must
+// be held by the caller.
+func (l *Limiter) evictExpiredLocked() {
+	if l.opts.IdleTTL == 0 {
+		return
+	}
+	cutoff := time.Now().Add(-l.opts.IdleTTL)
+	for el := l.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*entry).lastUsed.After(cutoff) {
+			break
+		}
+		l.removeLocked(el)
+		el = prev
+	}
+}
+
+// removeLocked stops and forgets the limiter held by el. l.mu must be held
+// by the caller.
+func (l *Limiter) removeLocked(el *list.Element) {
+	e := el.Value.(*entry)
+	e.limiter.Stop()
+	delete(l.entries, e.key)
+	l.lru.Remove(el)
+}
+
+// writeTooManyRequests writes a 429 response with standard rate-limit
+// headers derived from rl and the configured Interval.
+func (l *Limiter) writeTooManyRequests(w http.ResponseWriter, rl *ratelimit.Limiter) {
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.FormatUint(uint64(rl.GetLimit()), 10))
+	h.Set("X-RateLimit-Remaining", strconv.FormatUint(uint64(rl.Remaining()), 10))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(l.opts.Interval).Unix(), 10))
+	h.Set("Retry-After", strconv.Itoa(int(l.opts.Interval.Seconds())))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
@@ -2,75 +2,296 @@ package ratelimit
 
 import (
 	"context"
+	"errors"
 	"math"
+	"sync"
 	"time"
 )
 
+// ErrLimiterReset is returned by Wait when the limiter is reset or stopped
+// while the call is still waiting for a token. TakeWithConcurrency also
+// returns it when the limiter's capacity is zero, so no token can be reserved.
+var ErrLimiterReset = errors.New("ratelimit: limiter reset or stopped while waiting")
+
 // Limiter allows a burst of request during the defined duration
 type Limiter struct {
-	maxCount uint
-	count    uint
-	ticker   *time.Ticker
-	tokens   chan struct{}
-	ctx      context.Context
+	mu         sync.Mutex
+	cond       *sync.Cond
+	maxCount   uint
+	available  uint // tokens takeable in the current interval
+	debt       uint // tokens already promised to pending reservations
+	interval   time.Duration
+	generation uint64 // bumped by SleepandReset so a superseded run retires
+	stopped    bool
+	ctx        context.Context
 	// internal
 	cancelFunc context.CancelFunc
 }
 
-func (limiter *Limiter) run(ctx context.Context) {
+// run owns ticker and gen for its lifetime: both are passed in rather than
+// read off limiter, so a concurrent SleepandReset swapping them out for a
+// newer run cannot race with this one.
+func (limiter *Limiter) run(ctx context.Context, ticker *time.Ticker, gen uint64) {
+	defer ticker.Stop()
 	for {
-		if limiter.count == 0 {
-			<-limiter.ticker.C
-			limiter.count = limiter.maxCount
-		}
 		select {
 		case <-ctx.Done():
 			// Internal Context
-			limiter.ticker.Stop()
 			return
 		case <-limiter.ctx.Done():
-			limiter.ticker.Stop()
+			limiter.mu.Lock()
+			limiter.stopped = true
+			limiter.cond.Broadcast()
+			limiter.mu.Unlock()
 			return
-		case limiter.tokens <- struct{}{}:
-			limiter.count--
-		case <-limiter.ticker.C:
-			limiter.count = limiter.maxCount
+		case <-ticker.C:
+			limiter.mu.Lock()
+			if limiter.generation != gen {
+				// Superseded by a SleepandReset; let the newer run take over.
+				limiter.mu.Unlock()
+				return
+			}
+			paid := limiter.debt
+			if paid > limiter.maxCount {
+				paid = limiter.maxCount
+			}
+			limiter.debt -= paid
+			limiter.available = limiter.maxCount - paid
+			limiter.cond.Broadcast()
+			limiter.mu.Unlock()
 		}
 	}
 }
 
 // Take one token from the bucket
 func (rateLimiter *Limiter) Take() {
-	<-rateLimiter.tokens
+	rateLimiter.mu.Lock()
+	defer rateLimiter.mu.Unlock()
+
+	for !rateLimiter.stopped && rateLimiter.available == 0 {
+		rateLimiter.cond.Wait()
+	}
+	if rateLimiter.available > 0 {
+		rateLimiter.available--
+	}
+}
+
+// TakeN takes n tokens from the bucket, blocking until every one of them is
+// available
+func (rateLimiter *Limiter) TakeN(n uint) {
+	for i := uint(0); i < n; i++ {
+		rateLimiter.Take()
+	}
+}
+
+// Allow attempts to take one token without blocking, returning false if none
+// is currently available
+func (rateLimiter *Limiter) Allow() bool {
+	rateLimiter.mu.Lock()
+	defer rateLimiter.mu.Unlock()
+
+	if rateLimiter.available == 0 {
+		return false
+	}
+	rateLimiter.available--
+	return true
+}
+
+// Wait blocks until a token becomes available or ctx is done, whichever
+// happens first. It returns ctx.Err() if ctx is canceled, and ErrLimiterReset
+// if the limiter is reset or stopped while the wait is in progress.
+func (rateLimiter *Limiter) Wait(ctx context.Context) error {
+	if done := ctx.Done(); done != nil {
+		cancelWait := make(chan struct{})
+		defer close(cancelWait)
+		go func() {
+			select {
+			case <-done:
+				rateLimiter.mu.Lock()
+				rateLimiter.cond.Broadcast()
+				rateLimiter.mu.Unlock()
+			case <-cancelWait:
+			}
+		}()
+	}
+
+	rateLimiter.mu.Lock()
+	defer rateLimiter.mu.Unlock()
+
+	for {
+		if rateLimiter.stopped {
+			return ErrLimiterReset
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if rateLimiter.available > 0 {
+			rateLimiter.available--
+			return nil
+		}
+		rateLimiter.cond.Wait()
+	}
+}
+
+// Reservation represents a promise that tokens will become available after
+// waiting for Delay. Obtained from Limiter.Reserve or Limiter.ReserveN.
+type Reservation struct {
+	limiter   *Limiter
+	ok        bool
+	delay     time.Duration
+	immediate uint
+	deferred  uint
+	canceled  bool
+}
+
+// OK reports whether the reservation can ever be satisfied. It is false only
+// when the limiter's capacity can never supply the requested token count.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller should wait before the reserved tokens
+// are available.
+func (r *Reservation) Delay() time.Duration {
+	return r.delay
+}
+
+// Cancel returns the reserved tokens to the bucket. It is a no-op for a
+// reservation that is not OK or has already been canceled.
+func (r *Reservation) Cancel() {
+	if r == nil || !r.ok || r.canceled {
+		return
+	}
+	r.canceled = true
+
+	l := r.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.immediate > 0 {
+		l.available += r.immediate
+		if l.available > l.maxCount {
+			l.available = l.maxCount
+		}
+	}
+	if r.deferred > 0 {
+		// Deferred tokens still owed as debt haven't been paid out of
+		// available yet, so canceling them just forgives the debt. Any
+		// portion run has already paid (because Delay elapsed and a tick
+		// fired before Cancel was called) was taken out of available on
+		// our behalf, so it must be handed back directly or it's lost for
+		// the rest of the interval.
+		unpaid := r.deferred
+		if l.debt < unpaid {
+			unpaid = l.debt
+		}
+		l.debt -= unpaid
+		if paid := r.deferred - unpaid; paid > 0 {
+			l.available += paid
+			if l.available > l.maxCount {
+				l.available = l.maxCount
+			}
+		}
+	}
+	l.cond.Broadcast()
+}
+
+// Reserve reserves a single token. See ReserveN.
+func (rateLimiter *Limiter) Reserve() *Reservation {
+	return rateLimiter.ReserveN(1)
+}
+
+// ReserveN reserves n tokens and reports how long the caller must wait
+// before they become available. Unlike Take and Wait, ReserveN never
+// blocks: it schedules the tokens against future refill intervals so the
+// caller can sleep, do other work, or Cancel instead of parking a
+// goroutine.
+func (rateLimiter *Limiter) ReserveN(n uint) *Reservation {
+	rateLimiter.mu.Lock()
+	defer rateLimiter.mu.Unlock()
+
+	if rateLimiter.maxCount == 0 {
+		return &Reservation{limiter: rateLimiter, ok: false}
+	}
+
+	immediate := n
+	if rateLimiter.available < immediate {
+		immediate = rateLimiter.available
+	}
+	rateLimiter.available -= immediate
+
+	deferred := n - immediate
+	var delay time.Duration
+	if deferred > 0 {
+		owed := rateLimiter.debt + deferred
+		ticks := owed / rateLimiter.maxCount
+		if owed%rateLimiter.maxCount != 0 {
+			ticks++
+		}
+		delay = time.Duration(ticks) * rateLimiter.interval
+		rateLimiter.debt += deferred
+	}
+
+	return &Reservation{
+		limiter:   rateLimiter,
+		ok:        true,
+		delay:     delay,
+		immediate: immediate,
+		deferred:  deferred,
+	}
 }
 
 // GetLimit returns current rate limit per given duration
 func (ratelimiter *Limiter) GetLimit() uint {
+	ratelimiter.mu.Lock()
+	defer ratelimiter.mu.Unlock()
 	return ratelimiter.maxCount
 }
 
+// Remaining returns the number of tokens left in the current interval
+func (ratelimiter *Limiter) Remaining() uint {
+	ratelimiter.mu.Lock()
+	defer ratelimiter.mu.Unlock()
+	return ratelimiter.available
+}
+
 // SleepandReset stops timer removes all tokens and resets with new limit (used for Adaptive Ratelimiting)
 func (ratelimiter *Limiter) SleepandReset(sleepTime time.Duration, newLimit uint, duration time.Duration) {
 	// stop existing Limiter using internalContext
+	ratelimiter.mu.Lock()
+	ratelimiter.generation++
 	ratelimiter.cancelFunc()
-	// drain any token
-	close(ratelimiter.tokens)
-	<-ratelimiter.tokens
+	ratelimiter.stopped = true
+	ratelimiter.cond.Broadcast()
+	ratelimiter.mu.Unlock()
+
 	// sleep
 	time.Sleep(sleepTime)
+
 	//reset and start
-	ratelimiter.maxCount = newLimit
-	ratelimiter.count = newLimit
-	ratelimiter.ticker = time.NewTicker(duration)
-	ratelimiter.tokens = make(chan struct{})
 	ctx, cancel := context.WithCancel(context.TODO())
+	ticker := time.NewTicker(duration)
+
+	ratelimiter.mu.Lock()
+	ratelimiter.maxCount = newLimit
+	ratelimiter.available = newLimit
+	ratelimiter.debt = 0
+	ratelimiter.interval = duration
+	ratelimiter.stopped = false
 	ratelimiter.cancelFunc = cancel
-	go ratelimiter.run(ctx)
+	gen := ratelimiter.generation
+	ratelimiter.mu.Unlock()
+
+	go ratelimiter.run(ctx, ticker, gen)
 }
 
 // Stop the rate limiter canceling the internal context
 func (ratelimiter *Limiter) Stop() {
-	defer close(ratelimiter.tokens)
+	ratelimiter.mu.Lock()
+	ratelimiter.stopped = true
+	ratelimiter.cond.Broadcast()
+	ratelimiter.mu.Unlock()
+
 	if ratelimiter.cancelFunc != nil {
 		ratelimiter.cancelFunc()
 	}
@@ -82,13 +303,13 @@ func New(ctx context.Context, max uint, duration time.Duration) *Limiter {
 
 	limiter := &Limiter{
 		maxCount:   uint(max),
-		count:      uint(max),
-		ticker:     time.NewTicker(duration),
-		tokens:     make(chan struct{}),
+		available:  uint(max),
+		interval:   duration,
 		ctx:        ctx,
 		cancelFunc: cancel,
 	}
-	go limiter.run(internalctx)
+	limiter.cond = sync.NewCond(&limiter.mu)
+	go limiter.run(internalctx, time.NewTicker(duration), limiter.generation)
 
 	return limiter
 }
@@ -99,13 +320,13 @@ func NewUnlimited(ctx context.Context) *Limiter {
 
 	limiter := &Limiter{
 		maxCount:   math.MaxUint,
-		count:      math.MaxUint,
-		ticker:     time.NewTicker(time.Millisecond),
-		tokens:     make(chan struct{}),
+		available:  math.MaxUint,
+		interval:   time.Millisecond,
 		ctx:        ctx,
 		cancelFunc: cancel,
 	}
-	go limiter.run(internalctx)
+	limiter.cond = sync.NewCond(&limiter.mu)
+	go limiter.run(internalctx, time.NewTicker(time.Millisecond), limiter.generation)
 
 	return limiter
 }
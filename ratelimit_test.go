@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllowAndTake(t *testing.T) {
+	l := New(context.Background(), 2, time.Hour)
+	defer l.Stop()
+
+	if !l.Allow() {
+		t.Fatal("expected first Allow to succeed")
+	}
+	if !l.Allow() {
+		t.Fatal("expected second Allow to succeed")
+	}
+	if l.Allow() {
+		t.Fatal("expected third Allow to fail, bucket should be empty")
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Fatalf("Remaining() = %d, want 0", got)
+	}
+}
+
+func TestRefillOnInterval(t *testing.T) {
+	l := New(context.Background(), 1, 20*time.Millisecond)
+	defer l.Stop()
+
+	l.Take()
+	if l.Allow() {
+		t.Fatal("expected bucket to be empty immediately after Take")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if !l.Allow() {
+		t.Fatal("expected bucket to refill after the interval elapsed")
+	}
+}
+
+func TestWaitReturnsOnContextCancel(t *testing.T) {
+	l := New(context.Background(), 1, time.Hour)
+	defer l.Stop()
+	l.Take()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- l.Wait(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Wait() err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after ctx was canceled")
+	}
+}
+
+func TestWaitReturnsWhenLimiterStopped(t *testing.T) {
+	l := New(context.Background(), 1, time.Hour)
+	l.Take()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- l.Wait(context.Background()) }()
+
+	time.Sleep(20 * time.Millisecond)
+	l.Stop()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrLimiterReset) {
+			t.Fatalf("Wait() err = %v, want ErrLimiterReset", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the limiter was stopped")
+	}
+}
+
+func TestWaitReturnsWhenParentContextCanceled(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	l := New(parent, 1, time.Hour)
+	l.Take()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- l.Wait(context.Background()) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrLimiterReset) {
+			t.Fatalf("Wait() err = %v, want ErrLimiterReset", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the limiter's parent ctx was canceled")
+	}
+}
+
+func TestReserveNImmediateAndDeferred(t *testing.T) {
+	l := New(context.Background(), 2, 30*time.Millisecond)
+	defer l.Stop()
+
+	r := l.ReserveN(3)
+	if !r.OK() {
+		t.Fatal("expected reservation to be OK")
+	}
+	if r.Delay() <= 0 {
+		t.Fatalf("Delay() = %v, want > 0 for a reservation exceeding the burst", r.Delay())
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Fatalf("Remaining() = %d, want 0 immediately after reserving more than the burst", got)
+	}
+}
+
+func TestReserveNRejectsZeroCapacity(t *testing.T) {
+	l := New(context.Background(), 0, time.Hour)
+	defer l.Stop()
+
+	r := l.Reserve()
+	if r.OK() {
+		t.Fatal("expected reservation to be rejected when maxCount is 0")
+	}
+}
+
+func TestReservationCancelRestoresImmediateTokens(t *testing.T) {
+	l := New(context.Background(), 2, time.Hour)
+	defer l.Stop()
+
+	r := l.Reserve()
+	if got := l.Remaining(); got != 1 {
+		t.Fatalf("Remaining() = %d, want 1 after reserving", got)
+	}
+
+	r.Cancel()
+	if got := l.Remaining(); got != 2 {
+		t.Fatalf("Remaining() = %d, want 2 after canceling", got)
+	}
+}
+
+func TestReservationCancelRestoresPaidDeferredTokens(t *testing.T) {
+	l := New(context.Background(), 1, 30*time.Millisecond)
+	defer l.Stop()
+
+	l.Take() // drain the burst so the next reservation is deferred
+
+	r := l.Reserve()
+	if r.Delay() <= 0 {
+		t.Fatalf("Delay() = %v, want > 0", r.Delay())
+	}
+
+	// Let a refill tick pay the reservation's debt out of available before
+	// we cancel, simulating a caller whose Delay elapsed before a
+	// downstream step (e.g. acquiring a concurrency slot) failed.
+	time.Sleep(2 * r.Delay())
+	before := l.Remaining()
+
+	r.Cancel()
+
+	if got := l.Remaining(); got <= before {
+		t.Fatalf("Remaining() = %d after Cancel, want > %d: paid deferred token was lost", got, before)
+	}
+}
+
+func TestSleepAndResetAppliesNewLimit(t *testing.T) {
+	l := New(context.Background(), 1, time.Hour)
+	defer l.Stop()
+
+	l.SleepandReset(0, 5, 20*time.Millisecond)
+
+	if got := l.GetLimit(); got != 5 {
+		t.Fatalf("GetLimit() = %d, want 5", got)
+	}
+	if got := l.Remaining(); got != 5 {
+		t.Fatalf("Remaining() = %d, want 5 right after reset", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		if !l.Allow() {
+			t.Fatalf("Allow() failed on token %d, want all 5 tokens available", i)
+		}
+	}
+	if l.Allow() {
+		t.Fatal("expected bucket to be empty after taking the new limit")
+	}
+}
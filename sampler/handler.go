@@ -0,0 +1,63 @@
+package sampler
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/hlnths/ratelimit"
+)
+
+// Handler wraps an slog.Handler, dropping records that the sampler rejects.
+// Each dropped record increments a counter that is attached as a "dropped"
+// attribute to the next record let through, so suppressed volume isn't lost
+// silently.
+type Handler struct {
+	next    slog.Handler
+	sampler *LogSampler[slog.Record]
+	dropped *atomic.Int64
+}
+
+// NewHandler wraps next, sampling records through a LogSampler backed by l
+// and keyed by keyFn.
+func NewHandler(next slog.Handler, l *ratelimit.Limiter, keyFn func(slog.Record) string) *Handler {
+	return &Handler{
+		next:    next,
+		sampler: NewLogSampler(l, keyFn),
+		dropped: new(atomic.Int64),
+	}
+}
+
+// Enabled reports whether the wrapped handler is enabled for level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle samples record, dropping it if the sampler rejects it, and
+// otherwise forwards it to the wrapped handler annotated with how many
+// records were dropped since the last one let through.
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if !h.sampler.Allow(record) {
+		h.dropped.Add(1)
+		return nil
+	}
+
+	if dropped := h.dropped.Swap(0); dropped > 0 {
+		record = record.Clone()
+		record.AddAttrs(slog.Int64("dropped", dropped))
+	}
+
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs returns a Handler that adds attrs, sharing this Handler's
+// sampler and dropped-count state.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{next: h.next.WithAttrs(attrs), sampler: h.sampler, dropped: h.dropped}
+}
+
+// WithGroup returns a Handler that opens group name, sharing this Handler's
+// sampler and dropped-count state.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), sampler: h.sampler, dropped: h.dropped}
+}
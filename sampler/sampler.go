@@ -0,0 +1,45 @@
+// Package sampler suppresses spammy repeats of identical events while
+// always letting state transitions through, turning a ratelimit.Limiter
+// into a debounce for high-cardinality logs and metrics.
+package sampler
+
+import (
+	"sync"
+
+	"github.com/hlnths/ratelimit"
+)
+
+// LogSampler decides whether an event should be emitted: it always allows
+// an event whose key differs from the last one seen, and otherwise defers
+// to the underlying Limiter.
+type LogSampler[E any] struct {
+	limiter *ratelimit.Limiter
+	keyFn   func(E) string
+
+	mu      sync.Mutex
+	lastKey string
+	hasLast bool
+}
+
+// NewLogSampler creates a LogSampler backed by l, using keyFn to derive a
+// stable key for each event.
+func NewLogSampler[E any](l *ratelimit.Limiter, keyFn func(E) string) *LogSampler[E] {
+	return &LogSampler[E]{limiter: l, keyFn: keyFn}
+}
+
+// Allow reports whether event should be emitted, consuming a token from the
+// underlying Limiter when the event's key repeats the last one seen.
+func (s *LogSampler[E]) Allow(event E) bool {
+	key := s.keyFn(event)
+
+	s.mu.Lock()
+	changed := !s.hasLast || key != s.lastKey
+	s.lastKey = key
+	s.hasLast = true
+	s.mu.Unlock()
+
+	if changed {
+		return true
+	}
+	return s.limiter.Allow()
+}
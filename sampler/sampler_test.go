@@ -0,0 +1,38 @@
+package sampler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hlnths/ratelimit"
+)
+
+func TestLogSamplerAllowsKeyChanges(t *testing.T) {
+	l := ratelimit.New(context.Background(), 0, time.Hour)
+	defer l.Stop()
+	s := NewLogSampler(l, func(e string) string { return e })
+
+	if !s.Allow("a") {
+		t.Fatal("expected the first event to be allowed")
+	}
+	if !s.Allow("b") {
+		t.Fatal("expected an event with a different key to always be allowed")
+	}
+}
+
+func TestLogSamplerDebouncesRepeatedKey(t *testing.T) {
+	l := ratelimit.New(context.Background(), 1, time.Hour)
+	defer l.Stop()
+	s := NewLogSampler(l, func(e string) string { return e })
+
+	if !s.Allow("a") {
+		t.Fatal("expected the first event to be allowed")
+	}
+	if !s.Allow("a") {
+		t.Fatal("expected the repeat to be allowed: the limiter still has its one token")
+	}
+	if s.Allow("a") {
+		t.Fatal("expected the second repeat to be suppressed: the limiter's token is now spent")
+	}
+}
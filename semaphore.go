@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Semaphore caps the number of concurrent in-flight operations, independent
+// of the request rate enforced by a Limiter.
+type Semaphore struct {
+	slots chan struct{}
+}
+
+// NewSemaphore creates a Semaphore allowing up to n concurrent operations.
+func NewSemaphore(n uint) *Semaphore {
+	return &Semaphore{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until a slot is available or ctx is done.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAcquire acquires a slot without blocking, returning false if none is
+// currently available.
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release returns a slot previously obtained from Acquire or TryAcquire.
+func (s *Semaphore) Release() {
+	<-s.slots
+}
+
+// TakeWithConcurrency atomically reserves a rate-limit token from
+// rateLimiter and a concurrency slot from sem, so a caller can enforce
+// "<= N req/s AND <= M in-flight" without the two limits deadlocking against
+// each other. If the slot cannot be acquired before ctx is done, the token
+// is returned to rateLimiter. It returns ErrLimiterReset if rateLimiter has a
+// limit of zero and so can never grant the token.
+func (rateLimiter *Limiter) TakeWithConcurrency(sem *Semaphore, ctx context.Context) error {
+	reservation := rateLimiter.Reserve()
+	if !reservation.OK() {
+		return ErrLimiterReset
+	}
+
+	if reservation.Delay() > 0 {
+		timer := time.NewTimer(reservation.Delay())
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			reservation.Cancel()
+			return ctx.Err()
+		}
+	}
+
+	if err := sem.Acquire(ctx); err != nil {
+		reservation.Cancel()
+		return err
+	}
+
+	return nil
+}
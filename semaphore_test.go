@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreAcquireRelease(t *testing.T) {
+	s := NewSemaphore(1)
+
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() err = %v, want nil", err)
+	}
+	if s.TryAcquire() {
+		t.Fatal("expected TryAcquire to fail while the only slot is held")
+	}
+
+	s.Release()
+	if !s.TryAcquire() {
+		t.Fatal("expected TryAcquire to succeed after Release")
+	}
+}
+
+func TestSemaphoreAcquireBlocksUntilContextDone(t *testing.T) {
+	s := NewSemaphore(1)
+	if err := s.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() err = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := s.Acquire(ctx); err != ctx.Err() {
+		t.Fatalf("Acquire() err = %v, want %v", err, ctx.Err())
+	}
+}
+
+func TestTakeWithConcurrencySucceeds(t *testing.T) {
+	l := New(context.Background(), 1, time.Hour)
+	defer l.Stop()
+	sem := NewSemaphore(1)
+
+	if err := l.TakeWithConcurrency(sem, context.Background()); err != nil {
+		t.Fatalf("TakeWithConcurrency() err = %v, want nil", err)
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Fatalf("Remaining() = %d, want 0 after taking the only token", got)
+	}
+	if s := sem.TryAcquire(); s {
+		t.Fatal("expected the semaphore's only slot to be held")
+	}
+}
+
+func TestTakeWithConcurrencyReturnsTokenOnSlotTimeout(t *testing.T) {
+	l := New(context.Background(), 1, time.Hour)
+	defer l.Stop()
+	sem := NewSemaphore(1)
+	if err := sem.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() err = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := l.TakeWithConcurrency(sem, ctx); err != ctx.Err() {
+		t.Fatalf("TakeWithConcurrency() err = %v, want %v", err, ctx.Err())
+	}
+	if got := l.Remaining(); got != 1 {
+		t.Fatalf("Remaining() = %d, want 1: token should be returned when the semaphore can't be acquired", got)
+	}
+}